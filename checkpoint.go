@@ -0,0 +1,337 @@
+package wal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThejasNU/wal/types"
+	"github.com/ThejasNU/wal/utils"
+)
+
+// checkpointPrefix names the directories produced by Checkpoint, e.g.
+// "checkpoint.000003".
+const checkpointPrefix = "checkpoint."
+
+// CheckpointStats summarises the outcome of a single Checkpoint call.
+type CheckpointStats struct {
+	// Dir is the checkpoint directory's basename, e.g. "checkpoint.000003",
+	// matching the convention LastCheckpoint uses. Join it onto the
+	// WAL's directory to get a path.
+	Dir string
+
+	// Index is the highest segment index folded into the checkpoint.
+	Index int
+
+	// EntriesKept is the number of entries the keep filter let through.
+	EntriesKept int
+
+	// EntriesDropped is the number of entries the keep filter discarded.
+	EntriesDropped int
+
+	// SegmentsRemoved is the number of segment files deleted once the
+	// checkpoint was durably written.
+	SegmentsRemoved int
+}
+
+// Checkpoint folds the previous checkpoint's surviving entries (if any)
+// together with every sealed segment (all segments older than the one
+// currently being written to) into a new checkpoint.NNNNNN directory,
+// keeping only the entries for which keep returns true, and then removes
+// the segments it just folded and the previous checkpoint it superseded.
+// The directory is built under a temporary name and renamed into place
+// so a reader never observes a partially written checkpoint.
+//
+// Folding the previous checkpoint in is what makes each checkpoint
+// directory self-sufficient: LastCheckpoint/Replay only ever look at the
+// single most recent one, so if Checkpoint only folded newly sealed
+// segments, everything only preserved in an earlier checkpoint would be
+// silently lost the next time Checkpoint ran.
+//
+// This replaces relying on maxSegmentsNumber eviction, which drops the
+// oldest segment wholesale regardless of whether it still holds live
+// data; Checkpoint lets the caller decide what is still relevant.
+func (wal *WAL) Checkpoint(keep func(*types.WAL_Entry) bool) (CheckpointStats, error) {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	checkpointStart := time.Now()
+
+	if err := wal.Flush(); err != nil {
+		return CheckpointStats{}, fmt.Errorf("error while flushing before checkpoint: %v", err)
+	}
+
+	segmentFiles, err := wal.sealedSegmentFiles()
+	if err != nil {
+		return CheckpointStats{}, err
+	}
+
+	if len(segmentFiles) == 0 {
+		return CheckpointStats{}, nil
+	}
+
+	prevCheckpointName, _, err := LastCheckpoint(wal.directory)
+	if err != nil {
+		return CheckpointStats{}, err
+	}
+
+	checkpointIndex := int(wal.currentSegmentIndex) - 1
+	tmpDir := filepath.Join(wal.directory, fmt.Sprintf("%s%06d.tmp", checkpointPrefix, checkpointIndex))
+	finalDir := filepath.Join(wal.directory, fmt.Sprintf("%s%06d", checkpointPrefix, checkpointIndex))
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return CheckpointStats{}, err
+	}
+
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return CheckpointStats{}, err
+	}
+
+	stats := CheckpointStats{Dir: filepath.Base(finalDir), Index: checkpointIndex}
+
+	checkpointFile, err := utils.CreateSegmentFile(tmpDir, 0)
+	if err != nil {
+		return CheckpointStats{}, err
+	}
+
+	if err := writeSegmentHeader(checkpointFile, wal.codec); err != nil {
+		checkpointFile.Close()
+		return CheckpointStats{}, err
+	}
+
+	checkpointWriter := bufio.NewWriter(checkpointFile)
+
+	writeKept := func(entries []*types.WAL_Entry) error {
+		for _, entry := range entries {
+			if !keep(entry) {
+				stats.EntriesDropped++
+				continue
+			}
+
+			if err := writeEntryToWriter(checkpointWriter, entry, wal.codec); err != nil {
+				return err
+			}
+
+			stats.EntriesKept++
+		}
+
+		return nil
+	}
+
+	if prevCheckpointName != "" {
+		prevEntries, err := readEntriesFromCheckpoint(filepath.Join(wal.directory, prevCheckpointName), wal.metrics.observeCRCMismatch)
+		if err != nil {
+			checkpointFile.Close()
+			return CheckpointStats{}, err
+		}
+
+		if err := writeKept(prevEntries); err != nil {
+			checkpointFile.Close()
+			return CheckpointStats{}, err
+		}
+	}
+
+	for _, segmentFile := range segmentFiles {
+		file, err := os.OpenFile(segmentFile, os.O_RDONLY, 0644)
+		if err != nil {
+			checkpointFile.Close()
+			return CheckpointStats{}, err
+		}
+
+		entries, _, err := readAllEntriesFromFile(file, false, wal.metrics.observeCRCMismatch)
+		file.Close()
+		if err != nil {
+			checkpointFile.Close()
+			return CheckpointStats{}, err
+		}
+
+		if err := writeKept(entries); err != nil {
+			checkpointFile.Close()
+			return CheckpointStats{}, err
+		}
+	}
+
+	if err := checkpointWriter.Flush(); err != nil {
+		checkpointFile.Close()
+		return CheckpointStats{}, err
+	}
+
+	if err := checkpointFile.Sync(); err != nil {
+		checkpointFile.Close()
+		return CheckpointStats{}, err
+	}
+
+	if err := checkpointFile.Close(); err != nil {
+		return CheckpointStats{}, err
+	}
+
+	if err := os.RemoveAll(finalDir); err != nil {
+		return CheckpointStats{}, err
+	}
+
+	if err := os.Rename(tmpDir, finalDir); err != nil {
+		return CheckpointStats{}, err
+	}
+
+	for _, segmentFile := range segmentFiles {
+		if err := os.Remove(segmentFile); err != nil {
+			return stats, err
+		}
+
+		stats.SegmentsRemoved++
+	}
+
+	if prevCheckpointName != "" {
+		if err := os.RemoveAll(filepath.Join(wal.directory, prevCheckpointName)); err != nil {
+			return stats, err
+		}
+	}
+
+	wal.metrics.observeCheckpoint(time.Since(checkpointStart))
+
+	return stats, nil
+}
+
+// sealedSegmentFiles returns, in ascending order, the segment files
+// older than the one currently being written to.
+func (wal *WAL) sealedSegmentFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(wal.directory, fmt.Sprintf("%s*", utils.SegmentPrefix)))
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed []string
+	for _, file := range files {
+		segmentIdx, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(file), utils.SegmentPrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		if uint(segmentIdx) < wal.currentSegmentIndex {
+			sealed = append(sealed, file)
+		}
+	}
+
+	sort.Strings(sealed)
+
+	return sealed, nil
+}
+
+// LastCheckpoint returns the name and index of the most recent
+// checkpoint directory in dir. If no checkpoint exists, name is empty
+// and idx is -1.
+func LastCheckpoint(dir string) (name string, idx int, err error) {
+	checkpoints, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s*", checkpointPrefix)))
+	if err != nil {
+		return "", -1, err
+	}
+
+	lastIdx := -1
+	for _, checkpoint := range checkpoints {
+		base := filepath.Base(checkpoint)
+
+		checkpointIdx, err := strconv.Atoi(strings.TrimPrefix(base, checkpointPrefix))
+		if err != nil {
+			// skip partially written "checkpoint.NNNNNN.tmp" directories left behind by a crash
+			continue
+		}
+
+		if checkpointIdx > lastIdx {
+			lastIdx = checkpointIdx
+			name = base
+		}
+	}
+
+	return name, lastIdx, nil
+}
+
+// DeleteCheckpoints removes every checkpoint directory in dir whose
+// index is less than maxIndex, keeping the most recent one(s) a caller
+// still needs.
+func DeleteCheckpoints(dir string, maxIndex int) error {
+	checkpoints, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s*", checkpointPrefix)))
+	if err != nil {
+		return err
+	}
+
+	for _, checkpoint := range checkpoints {
+		checkpointIdx, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(checkpoint), checkpointPrefix))
+		if err != nil {
+			continue
+		}
+
+		if checkpointIdx < maxIndex {
+			if err := os.RemoveAll(checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readEntriesFromCheckpoint reads every entry out of a checkpoint
+// directory produced by Checkpoint.
+func readEntriesFromCheckpoint(dir string, onCRCMismatch func()) ([]*types.WAL_Entry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s*", utils.SegmentPrefix)))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	var entries []*types.WAL_Entry
+	for _, fileName := range files {
+		file, err := os.OpenFile(fileName, os.O_RDONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		fileEntries, _, err := readAllEntriesFromFile(file, false, onCRCMismatch)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, fileEntries...)
+	}
+
+	return entries, nil
+}
+
+// Replay reconstructs the WAL's entries for recovery: it streams
+// whatever survived in the latest checkpoint directory (if any) and
+// then continues with the segments written after that checkpoint was
+// taken. This keeps recovery time proportional to checkpoint size plus
+// the tail instead of the whole WAL, the way ReadAllFromIndex alone
+// would require.
+func (wal *WAL) Replay() ([]*types.WAL_Entry, error) {
+	name, idx, err := LastCheckpoint(wal.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*types.WAL_Entry
+	fromIndex := 0
+
+	if name != "" {
+		entries, err = readEntriesFromCheckpoint(filepath.Join(wal.directory, name), wal.metrics.observeCRCMismatch)
+		if err != nil {
+			return nil, err
+		}
+
+		fromIndex = idx + 1
+	}
+
+	tailEntries, err := wal.ReadAllFromIndex(fromIndex, false)
+	if err != nil {
+		return entries, err
+	}
+
+	return append(entries, tailEntries...), nil
+}
@@ -0,0 +1,111 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ThejasNU/wal/types"
+)
+
+func TestCheckpointRenamesAtomicallyAndFiltersEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := GetWAL(dir, Options{MaxSegmentSize: 1 << 20, MaxSegments: 10})
+	if err != nil {
+		t.Fatalf("GetWAL: %v", err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := w.WriteEntry([]byte{byte(i)}); err != nil {
+			t.Fatalf("WriteEntry: %v", err)
+		}
+	}
+
+	// seal the segment these entries landed in so Checkpoint has
+	// something to fold
+	if err := w.changeLogSegment(); err != nil {
+		t.Fatalf("changeLogSegment: %v", err)
+	}
+
+	keepEven := func(entry *types.WAL_Entry) bool {
+		return entry.GetLogSequenceNumber()%2 == 0
+	}
+
+	stats, err := w.Checkpoint(keepEven)
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	if stats.EntriesKept != 2 || stats.EntriesDropped != 3 {
+		t.Fatalf("got kept=%d dropped=%d, want kept=2 dropped=3", stats.EntriesKept, stats.EntriesDropped)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, stats.Dir)); err != nil {
+		t.Fatalf("checkpoint directory missing: %v", err)
+	}
+
+	tmpDir := filepath.Join(dir, stats.Dir+".tmp")
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
+		t.Fatalf("tmp checkpoint directory %s should not survive a successful Checkpoint", tmpDir)
+	}
+
+	entries, err := readEntriesFromCheckpoint(filepath.Join(dir, stats.Dir), nil)
+	if err != nil {
+		t.Fatalf("readEntriesFromCheckpoint: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries in checkpoint, want 2", len(entries))
+	}
+}
+
+// TestCheckpointFoldsPreviousCheckpointForward guards against a second
+// Checkpoint call silently losing everything the first one preserved:
+// each checkpoint directory must be self-sufficient, since Replay only
+// ever reads the single most recent one.
+func TestCheckpointFoldsPreviousCheckpointForward(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := GetWAL(dir, Options{MaxSegmentSize: 1 << 20, MaxSegments: 10})
+	if err != nil {
+		t.Fatalf("GetWAL: %v", err)
+	}
+	defer w.Close()
+
+	keepAll := func(entry *types.WAL_Entry) bool { return true }
+
+	for i := 0; i < 10; i++ {
+		if err := w.WriteEntry([]byte{byte(i)}); err != nil {
+			t.Fatalf("WriteEntry: %v", err)
+		}
+	}
+	if err := w.changeLogSegment(); err != nil {
+		t.Fatalf("changeLogSegment: %v", err)
+	}
+	if _, err := w.Checkpoint(keepAll); err != nil {
+		t.Fatalf("first Checkpoint: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := w.WriteEntry([]byte{byte(i)}); err != nil {
+			t.Fatalf("WriteEntry: %v", err)
+		}
+	}
+	if err := w.changeLogSegment(); err != nil {
+		t.Fatalf("changeLogSegment: %v", err)
+	}
+	if _, err := w.Checkpoint(keepAll); err != nil {
+		t.Fatalf("second Checkpoint: %v", err)
+	}
+
+	entries, err := w.Replay()
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if len(entries) != 20 {
+		t.Fatalf("got %d entries after two checkpoints, want 20 (the first checkpoint's entries must survive the second)", len(entries))
+	}
+}
@@ -0,0 +1,107 @@
+package wal
+
+import (
+	"fmt"
+
+	"github.com/ThejasNU/wal/types"
+	"github.com/ThejasNU/wal/utils"
+	"github.com/golang/snappy"
+)
+
+// segment header bytes identifying which RecordCodec encoded the
+// records that follow. Every segment starts with exactly one of these,
+// written once when the segment is created, so readers auto-detect the
+// format instead of needing it configured out of band.
+const (
+	protoMagic       byte = 1
+	snappyProtoMagic byte = 2
+)
+
+// RecordCodec encodes and decodes a single WAL_Entry's on-disk
+// representation, excluding the [size] framing that wraps every record.
+type RecordCodec interface {
+	// Magic identifies this codec in a segment's header byte.
+	Magic() byte
+
+	// Encode serializes entry into this codec's wire format.
+	Encode(entry *types.WAL_Entry) []byte
+
+	// Decode deserializes data produced by Encode back into an entry,
+	// verifying its CRC.
+	Decode(data []byte) (*types.WAL_Entry, error)
+
+	// DecodeLenient best-effort decodes data even if its CRC does not
+	// verify, returning nil if data cannot be parsed at all. Repair
+	// uses this to tell whether a torn trailing record it is about to
+	// discard held a checkpoint entry.
+	DecodeLenient(data []byte) *types.WAL_Entry
+}
+
+// ProtoCodec is the original on-disk format: a raw protobuf-marshaled
+// WAL_Entry.
+type ProtoCodec struct{}
+
+func (ProtoCodec) Magic() byte { return protoMagic }
+
+func (ProtoCodec) Encode(entry *types.WAL_Entry) []byte {
+	return utils.MustMarshal(entry)
+}
+
+func (ProtoCodec) Decode(data []byte) (*types.WAL_Entry, error) {
+	return utils.UnmarshalAndVerifyEntry(data)
+}
+
+func (ProtoCodec) DecodeLenient(data []byte) *types.WAL_Entry {
+	entry, err := utils.UnmarshalEntry(data)
+	if err != nil {
+		return nil
+	}
+
+	return entry
+}
+
+// SnappyProtoCodec Snappy-compresses the protobuf-marshaled WAL_Entry,
+// trading a little CPU for a smaller on-disk footprint.
+type SnappyProtoCodec struct{}
+
+func (SnappyProtoCodec) Magic() byte { return snappyProtoMagic }
+
+func (SnappyProtoCodec) Encode(entry *types.WAL_Entry) []byte {
+	return snappy.Encode(nil, utils.MustMarshal(entry))
+}
+
+func (SnappyProtoCodec) Decode(data []byte) (*types.WAL_Entry, error) {
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("snappy: %v", err)
+	}
+
+	return utils.UnmarshalAndVerifyEntry(decoded)
+}
+
+func (SnappyProtoCodec) DecodeLenient(data []byte) *types.WAL_Entry {
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil
+	}
+
+	entry, err := utils.UnmarshalEntry(decoded)
+	if err != nil {
+		return nil
+	}
+
+	return entry
+}
+
+// codecForMagic returns the RecordCodec identified by a segment
+// header's magic byte.
+func codecForMagic(magic byte) (RecordCodec, error) {
+	switch magic {
+	case protoMagic:
+		return ProtoCodec{}, nil
+	case snappyProtoMagic:
+		return SnappyProtoCodec{}, nil
+	default:
+		return nil, fmt.Errorf("wal: unrecognised segment codec magic byte %d", magic)
+	}
+}
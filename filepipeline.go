@@ -0,0 +1,114 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/ThejasNU/wal/utils"
+)
+
+// pipelineFilePrefix names the temporary files filePipeline prepares
+// ahead of time. It deliberately does not start with utils.SegmentPrefix
+// so glob-based segment listing never picks up an in-flight file.
+const pipelineFilePrefix = "pipeline-segment-"
+
+// filePipeline runs in the background creating and preallocating the
+// next segment file to fileSize bytes, as in etcd's WAL, so
+// changeLogSegment can consume an already-sized file instead of paying
+// the os.Create-plus-first-write-extension cost synchronously on the
+// write path.
+type filePipeline struct {
+	directory string
+	fileSize  int64
+
+	counter uint64
+
+	files chan *os.File
+	errc  chan error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newFilePipeline starts a filePipeline and its background goroutine.
+// It is torn down by calling Close, or automatically once ctx is
+// cancelled.
+func newFilePipeline(ctx context.Context, directory string, fileSize int64) *filePipeline {
+	pipelineCtx, cancel := context.WithCancel(ctx)
+
+	fp := &filePipeline{
+		directory: directory,
+		fileSize:  fileSize,
+		files:     make(chan *os.File),
+		errc:      make(chan error, 1),
+		ctx:       pipelineCtx,
+		cancel:    cancel,
+	}
+
+	go fp.run()
+
+	return fp
+}
+
+// Get hands off the next preallocated segment file, blocking until one
+// is ready.
+func (fp *filePipeline) Get() (*os.File, error) {
+	select {
+	case file := <-fp.files:
+		return file, nil
+	case err := <-fp.errc:
+		return nil, err
+	}
+}
+
+// Close stops the pipeline and removes any file it had already
+// prepared but not yet handed off.
+func (fp *filePipeline) Close() {
+	fp.cancel()
+
+	for file := range fp.files {
+		os.Remove(file.Name())
+		file.Close()
+	}
+}
+
+func (fp *filePipeline) run() {
+	defer close(fp.files)
+
+	for {
+		file, err := fp.alloc()
+		if err != nil {
+			fp.errc <- err
+			return
+		}
+
+		select {
+		case fp.files <- file:
+		case <-fp.ctx.Done():
+			os.Remove(file.Name())
+			file.Close()
+			return
+		}
+	}
+}
+
+func (fp *filePipeline) alloc() (*os.File, error) {
+	seq := atomic.AddUint64(&fp.counter, 1)
+	filePath := filepath.Join(fp.directory, fmt.Sprintf("%s%d", pipelineFilePrefix, seq))
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := utils.Preallocate(file, fp.fileSize); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return nil, fmt.Errorf("failed to preallocate segment file: %v", err)
+	}
+
+	return file, nil
+}
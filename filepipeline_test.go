@@ -0,0 +1,73 @@
+package wal
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilePipelineHandsOffDistinctFiles(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fp := newFilePipeline(ctx, dir, 4096)
+	defer fp.Close()
+
+	first, err := fp.Get()
+	if err != nil {
+		t.Fatalf("first Get: %v", err)
+	}
+	defer first.Close()
+
+	if got := filepath.Base(first.Name()); !strings.HasPrefix(got, pipelineFilePrefix) {
+		t.Fatalf("got file name %q, want prefix %q", got, pipelineFilePrefix)
+	}
+
+	second, err := fp.Get()
+	if err != nil {
+		t.Fatalf("second Get: %v", err)
+	}
+	defer second.Close()
+
+	if first.Name() == second.Name() {
+		t.Fatalf("expected successive Get calls to hand off distinct files")
+	}
+}
+
+// TestFilePipelineCloseRemovesUnconsumedFile checks the other half of
+// the rename-handoff: a file the pipeline prepared but that nobody
+// called Get for yet must not leak on Close.
+func TestFilePipelineCloseRemovesUnconsumedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	fp := newFilePipeline(context.Background(), dir, 4096)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		files, err := filepath.Glob(filepath.Join(dir, pipelineFilePrefix+"*"))
+		if err != nil {
+			t.Fatalf("Glob: %v", err)
+		}
+		if len(files) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for filePipeline to prepare a file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fp.Close()
+
+	files, err := filepath.Glob(filepath.Join(dir, pipelineFilePrefix+"*"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+
+	if len(files) != 0 {
+		t.Fatalf("expected Close to remove the unconsumed pipeline file, found %v", files)
+	}
+}
@@ -0,0 +1,197 @@
+package wal
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors for a WAL instance. A nil
+// *Metrics is safe to use everywhere it is read: every method is a
+// no-op when the receiver is nil, so callers can opt out of
+// instrumentation by leaving wal.metrics unset.
+type Metrics struct {
+	fsyncDuration      prometheus.Summary
+	bytesWritten       prometheus.Counter
+	entriesWritten     prometheus.Counter
+	segmentRotations   prometheus.Counter
+	segmentsDeleted    prometheus.Counter
+	checkpointsCreated prometheus.Counter
+	checkpointDuration prometheus.Summary
+	crcMismatches      prometheus.Counter
+	currentSegmentIdx  prometheus.Gauge
+	bufferedBytes      prometheus.Gauge
+	groupCommitSize    prometheus.Summary
+	groupCommitLatency prometheus.Summary
+}
+
+// newMetrics builds the Metrics collectors, namespaced as
+// namespace_subsystem_*, and registers them with reg. Passing a nil reg
+// still builds usable collectors, it just skips registration.
+func newMetrics(reg prometheus.Registerer, namespace, subsystem string) *Metrics {
+	m := &Metrics{
+		fsyncDuration: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  subsystem,
+			Name:       "fsync_duration_seconds",
+			Help:       "Duration of WAL segment fsync calls.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+		bytesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "bytes_written_total",
+			Help:      "Total number of entry bytes written to the WAL.",
+		}),
+		entriesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "entries_written_total",
+			Help:      "Total number of entries written to the WAL.",
+		}),
+		segmentRotations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "segment_rotations_total",
+			Help:      "Total number of times the WAL rotated to a new segment.",
+		}),
+		segmentsDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "segments_deleted_total",
+			Help:      "Total number of oldest segments deleted by maxSegmentsNumber eviction.",
+		}),
+		checkpointsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "checkpoints_created_total",
+			Help:      "Total number of checkpoints successfully created.",
+		}),
+		checkpointDuration: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  subsystem,
+			Name:       "checkpoint_duration_seconds",
+			Help:       "Duration of Checkpoint calls.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+		crcMismatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "crc_mismatches_total",
+			Help:      "Total number of entries rejected while reading due to a CRC mismatch.",
+		}),
+		currentSegmentIdx: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "current_segment_index",
+			Help:      "Index of the segment currently being written to.",
+		}),
+		bufferedBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "buffered_bytes",
+			Help:      "Number of bytes buffered in memory but not yet flushed to the current segment.",
+		}),
+		groupCommitSize: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  subsystem,
+			Name:       "group_commit_size",
+			Help:       "Number of entries coalesced into a single fsync in WBLMode.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+		groupCommitLatency: prometheus.NewSummary(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  subsystem,
+			Name:       "group_commit_latency_seconds",
+			Help:       "Duration of the fsync backing a WBLMode group commit.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+	}
+
+	if reg != nil {
+		reg.MustRegister(
+			m.fsyncDuration,
+			m.bytesWritten,
+			m.entriesWritten,
+			m.segmentRotations,
+			m.segmentsDeleted,
+			m.checkpointsCreated,
+			m.checkpointDuration,
+			m.crcMismatches,
+			m.currentSegmentIdx,
+			m.bufferedBytes,
+			m.groupCommitSize,
+			m.groupCommitLatency,
+		)
+	}
+
+	return m
+}
+
+func (m *Metrics) observeFsync(d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.fsyncDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) observeWrite(bytes int) {
+	if m == nil {
+		return
+	}
+
+	m.entriesWritten.Inc()
+	m.bytesWritten.Add(float64(bytes))
+}
+
+func (m *Metrics) observeRotation(currentSegmentIdx uint) {
+	if m == nil {
+		return
+	}
+
+	m.segmentRotations.Inc()
+	m.currentSegmentIdx.Set(float64(currentSegmentIdx))
+}
+
+func (m *Metrics) observeSegmentDeleted() {
+	if m == nil {
+		return
+	}
+
+	m.segmentsDeleted.Inc()
+}
+
+func (m *Metrics) observeCheckpoint(d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.checkpointsCreated.Inc()
+	m.checkpointDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) observeGroupCommit(size int, d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.groupCommitSize.Observe(float64(size))
+	m.groupCommitLatency.Observe(d.Seconds())
+}
+
+func (m *Metrics) observeCRCMismatch() {
+	if m == nil {
+		return
+	}
+
+	m.crcMismatches.Inc()
+}
+
+func (m *Metrics) setBufferedBytes(n int) {
+	if m == nil {
+		return
+	}
+
+	m.bufferedBytes.Set(float64(n))
+}
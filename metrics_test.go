@@ -0,0 +1,63 @@
+package wal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_golang/prometheus/dto"
+)
+
+func TestMetricsRegisterAndObserve(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newMetrics(reg, "test", "wal")
+
+	m.observeWrite(10)
+	m.observeRotation(3)
+	m.observeCheckpoint(5 * time.Millisecond)
+	m.observeCRCMismatch()
+	m.setBufferedBytes(42)
+	m.observeGroupCommit(4, time.Millisecond)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	if got := byName["test_wal_entries_written_total"]; got == nil || len(got.Metric) != 1 || got.Metric[0].GetCounter().GetValue() != 1 {
+		t.Fatalf("entries_written_total not observed correctly: %+v", got)
+	}
+
+	if got := byName["test_wal_current_segment_index"]; got == nil || len(got.Metric) != 1 || got.Metric[0].GetGauge().GetValue() != 3 {
+		t.Fatalf("current_segment_index not observed correctly: %+v", got)
+	}
+
+	if got := byName["test_wal_buffered_bytes"]; got == nil || len(got.Metric) != 1 || got.Metric[0].GetGauge().GetValue() != 42 {
+		t.Fatalf("buffered_bytes not observed correctly: %+v", got)
+	}
+
+	if got := byName["test_wal_crc_mismatches_total"]; got == nil || len(got.Metric) != 1 || got.Metric[0].GetCounter().GetValue() != 1 {
+		t.Fatalf("crc_mismatches_total not observed correctly: %+v", got)
+	}
+}
+
+// TestNilMetricsAreNoOps guards the nil-safety every Metrics method
+// documents: a WAL built via GetWAL (not GetWALWithMetrics) calls these
+// on a nil *Metrics on every hot path.
+func TestNilMetricsAreNoOps(t *testing.T) {
+	var m *Metrics
+
+	m.observeFsync(time.Millisecond)
+	m.observeWrite(10)
+	m.observeRotation(1)
+	m.observeSegmentDeleted()
+	m.observeCheckpoint(time.Millisecond)
+	m.observeGroupCommit(1, time.Millisecond)
+	m.observeCRCMismatch()
+	m.setBufferedBytes(1)
+}
@@ -0,0 +1,27 @@
+package wal
+
+// Mode selects between write-ahead and write-behind semantics for a
+// WAL.
+type Mode int
+
+const (
+	// WALMode is the original, fsync-critical mode: entries replay in
+	// strict append order and durability is controlled per-flush by
+	// Options.EnableFsync.
+	WALMode Mode = iota
+
+	// WBLMode (write-behind log) favours throughput over per-entry
+	// durability: entries are still appended in order, but flushes
+	// coalesce Options.GroupCommitSize entries into a single fsync
+	// instead of syncing on every flush interval.
+	WBLMode
+)
+
+func (mode Mode) String() string {
+	switch mode {
+	case WBLMode:
+		return "WBL"
+	default:
+		return "WAL"
+	}
+}
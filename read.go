@@ -23,7 +23,7 @@ func (wal *WAL) ReadAllFromCurrent(fromCheckpoint bool) ([]*types.WAL_Entry, err
 	}
 	defer file.Close()
 
-	entries, checkPointLSN, err := readAllEntriesFromFile(file, fromCheckpoint)
+	entries, checkPointLSN, err := readAllEntriesFromFile(file, fromCheckpoint, wal.metrics.observeCRCMismatch)
 	if err != nil {
 		return entries, err
 	}
@@ -62,7 +62,7 @@ func (wal *WAL) ReadAllFromIndex(index int, fromCheckpoint bool) ([]*types.WAL_E
 			return nil, err
 		}
 
-		entriesFromCurrentSegment, checkPointLSN, err := readAllEntriesFromFile(file, fromCheckpoint)
+		entriesFromCurrentSegment, checkPointLSN, err := readAllEntriesFromFile(file, fromCheckpoint, wal.metrics.observeCRCMismatch)
 		if err != nil {
 			return entries, err
 		}
@@ -78,11 +78,12 @@ func (wal *WAL) ReadAllFromIndex(index int, fromCheckpoint bool) ([]*types.WAL_E
 	return entries, nil
 }
 
-func readAllEntriesFromFile(file *os.File, fromCheckPoint bool) ([]*types.WAL_Entry, uint64, error) {
+func readAllEntriesFromFile(file *os.File, fromCheckPoint bool, onCRCMismatch func()) ([]*types.WAL_Entry, uint64, error) {
 	var entries []*types.WAL_Entry
 	var checkPointLSN uint64 = 0
 
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
+	codec, err := readSegmentHeader(file)
+	if err != nil {
 		return entries, checkPointLSN, err
 	}
 
@@ -100,8 +101,12 @@ func readAllEntriesFromFile(file *os.File, fromCheckPoint bool) ([]*types.WAL_En
 			return entries, checkPointLSN, err
 		}
 
-		entry, err := utils.UnmarshalAndVerifyEntry(data)
+		entry, err := codec.Decode(data)
 		if err != nil {
+			if onCRCMismatch != nil {
+				onCRCMismatch()
+			}
+
 			return entries, checkPointLSN, err
 		}
 
@@ -137,13 +142,18 @@ func (wal *WAL) getLastEntryInCurrentSegment() (*types.WAL_Entry, error) {
 	}
 	defer file.Close()
 
+	codec, err := readSegmentHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
 	var prevSize int32
 	var offset int64
 	var entry *types.WAL_Entry
 
 	for {
 		var size int32
-		if err := binary.Read(file, binary.LittleEndian, size); err != nil {
+		if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
 			if err == io.EOF {
 				if offset == 0 {
 					return entry, nil
@@ -158,7 +168,7 @@ func (wal *WAL) getLastEntryInCurrentSegment() (*types.WAL_Entry, error) {
 					return nil, err
 				}
 
-				entry, err = utils.UnmarshalAndVerifyEntry(data)
+				entry, err = codec.Decode(data)
 				if err != nil {
 					return nil, err
 				}
@@ -0,0 +1,128 @@
+package wal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ThejasNU/wal/utils"
+)
+
+// Repair scans the last (highest-indexed) segment in directory forward,
+// record by record, using the segment's own [size][codec-encoded entry]
+// framing. On the first record that fails to read cleanly - a short
+// read of the size prefix, a short read of the body, or a CRC mismatch -
+// it truncates the segment file to the byte offset immediately before
+// that record and returns how many bytes were discarded. It refuses to
+// truncate past a checkpoint entry; use GetWAL's AllowCheckpointLoss
+// option for an unattended open that should proceed anyway.
+//
+// Without this, a partially-written trailing entry left behind by a
+// crash makes getLastEntryInCurrentSegment and ReadAllFromCurrent
+// return errors, leaving the WAL unopenable.
+func Repair(directory string) (truncatedBytes int64, err error) {
+	return repair(directory, false)
+}
+
+func repair(directory string, allowCheckpointLoss bool) (int64, error) {
+	files, err := filepath.Glob(filepath.Join(directory, fmt.Sprintf("%s*", utils.SegmentPrefix)))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	lastSegmentId, err := utils.FindLastSegmentId(files)
+	if err != nil {
+		return 0, err
+	}
+
+	segmentPath := filepath.Join(directory, fmt.Sprintf("%s%d", utils.SegmentPrefix, lastSegmentId))
+
+	file, err := os.OpenFile(segmentPath, os.O_RDWR, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	validOffset, wouldDropCheckpoint, err := scanForTornTail(file)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	discarded := info.Size() - validOffset
+	if discarded <= 0 {
+		return 0, nil
+	}
+
+	if wouldDropCheckpoint && !allowCheckpointLoss {
+		return 0, fmt.Errorf("wal: repairing %s would discard %d bytes holding a checkpoint entry; set AllowCheckpointLoss to proceed anyway", segmentPath, discarded)
+	}
+
+	if err := file.Truncate(validOffset); err != nil {
+		return 0, err
+	}
+
+	log.Printf("wal: repaired %s, discarded %d bytes of a torn trailing record", segmentPath, discarded)
+
+	return discarded, nil
+}
+
+// scanForTornTail walks file's records from the start and returns the
+// byte offset just past the last record that read and CRC-verified
+// cleanly, along with whether any fully read record from the first bad
+// one onward (all of which truncation discards) leniently decodes, CRC
+// aside, as a checkpoint entry.
+func scanForTornTail(file *os.File) (validOffset int64, wouldDropCheckpoint bool, err error) {
+	codec, err := readSegmentHeader(file)
+	if err != nil {
+		return 0, false, err
+	}
+
+	offset := int64(segmentHeaderSize)
+
+	for {
+		var size int32
+		if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
+			// EOF, or a short read of the size prefix itself: nothing more to inspect
+			return offset, false, nil
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(file, data); err != nil {
+			// torn body: too little is left to tell what it held
+			return offset, false, nil
+		}
+
+		if _, decodeErr := codec.Decode(data); decodeErr != nil {
+			// every record from here on is discarded too, so check each
+			// of them for a checkpoint entry, not just this one
+			for {
+				if lenientEntry := codec.DecodeLenient(data); lenientEntry != nil && lenientEntry.GetIsCheckpoint() {
+					return offset, true, nil
+				}
+
+				if err := binary.Read(file, binary.LittleEndian, &size); err != nil || size < 0 {
+					return offset, false, nil
+				}
+
+				data = make([]byte, size)
+				if _, err := io.ReadFull(file, data); err != nil {
+					return offset, false, nil
+				}
+			}
+		}
+
+		offset += int64(binary.Size(size)) + int64(len(data))
+	}
+}
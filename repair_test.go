@@ -0,0 +1,140 @@
+package wal
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestRepairTruncatesTornTrailingRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := GetWAL(dir, Options{MaxSegmentSize: 1 << 20, MaxSegments: 10})
+	if err != nil {
+		t.Fatalf("GetWAL: %v", err)
+	}
+
+	if err := w.WriteEntry([]byte("first")); err != nil {
+		t.Fatalf("WriteEntry: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	segmentPath := w.currentSegment.Name()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	info, err := os.Stat(segmentPath)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	validSize := info.Size()
+
+	// simulate a crash mid-write: a size prefix for a record whose body
+	// never fully made it to disk
+	file, err := os.OpenFile(segmentPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if err := binary.Write(file, binary.LittleEndian, int32(100)); err != nil {
+		t.Fatalf("binary.Write: %v", err)
+	}
+	if _, err := file.Write([]byte("short")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	file.Close()
+
+	discarded, err := Repair(dir)
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+
+	if discarded == 0 {
+		t.Fatalf("expected Repair to discard the torn trailing record")
+	}
+
+	repaired, err := os.Stat(segmentPath)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if repaired.Size() != validSize {
+		t.Fatalf("got repaired size %d, want %d", repaired.Size(), validSize)
+	}
+}
+
+func TestRepairRefusesToDropACheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := GetWAL(dir, Options{MaxSegmentSize: 1 << 20, MaxSegments: 10})
+	if err != nil {
+		t.Fatalf("GetWAL: %v", err)
+	}
+
+	if err := w.CreateCheckpoint([]byte("chk")); err != nil {
+		t.Fatalf("CreateCheckpoint: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	segmentPath := w.currentSegment.Name()
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	file, err := os.OpenFile(segmentPath, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+
+	codec, err := readSegmentHeader(file)
+	if err != nil {
+		file.Close()
+		t.Fatalf("readSegmentHeader: %v", err)
+	}
+
+	var size int32
+	if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
+		file.Close()
+		t.Fatalf("binary.Read: %v", err)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		file.Close()
+		t.Fatalf("ReadFull: %v", err)
+	}
+
+	entry, err := codec.Decode(data)
+	if err != nil {
+		file.Close()
+		t.Fatalf("Decode: %v", err)
+	}
+
+	// break the CRC so the record reads back as corrupt rather than
+	// simply absent
+	entry.CRC++
+
+	corrupted := codec.Encode(entry)
+	if len(corrupted) != len(data) {
+		file.Close()
+		t.Fatalf("corrupted encoding changed length: got %d, want %d", len(corrupted), len(data))
+	}
+
+	if _, err := file.WriteAt(corrupted, int64(segmentHeaderSize)+int64(binary.Size(size))); err != nil {
+		file.Close()
+		t.Fatalf("WriteAt: %v", err)
+	}
+	file.Close()
+
+	if _, err := repair(dir, false); err == nil {
+		t.Fatalf("expected repair to refuse truncating past a checkpoint entry")
+	}
+
+	if _, err := repair(dir, true); err != nil {
+		t.Fatalf("AllowCheckpointLoss should let repair proceed: %v", err)
+	}
+}
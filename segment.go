@@ -0,0 +1,40 @@
+package wal
+
+import (
+	"io"
+	"os"
+)
+
+// segmentHeaderSize is the number of bytes every segment starts with:
+// a single byte naming the RecordCodec used for the records in it.
+const segmentHeaderSize = 1
+
+// writeSegmentHeader stamps a freshly created segment file with codec's
+// magic byte. It must be called exactly once, before any records are
+// written.
+func writeSegmentHeader(file *os.File, codec RecordCodec) error {
+	_, err := file.Write([]byte{codec.Magic()})
+	return err
+}
+
+// readSegmentHeader reads the codec magic byte from the start of file
+// and leaves the file positioned right after it, ready to read the
+// first record. An empty file (no header yet) is treated as ProtoCodec,
+// the default.
+func readSegmentHeader(file *os.File) (RecordCodec, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, segmentHeaderSize)
+
+	if _, err := io.ReadFull(file, header); err != nil {
+		if err == io.EOF {
+			return ProtoCodec{}, nil
+		}
+
+		return nil, err
+	}
+
+	return codecForMagic(header[0])
+}
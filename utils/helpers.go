@@ -48,20 +48,26 @@ func CreateSegmentFile(directory string, segmentId int) (*os.File, error) {
 	return file, nil
 }
 
-// unmarshals the given data into wal entry
-// also verifies CRC32, returns only if it is valid
+// unmarshals the given data into wal entry and verifies CRC32, returning
+// an error rather than panicking on either a malformed protobuf payload
+// or a CRC mismatch - callers like Repair's scanForTornTail rely on this
+// to tell a torn/garbled trailing record from a clean one without
+// crashing the process.
 func UnmarshalAndVerifyEntry(data []byte) (*types.WAL_Entry, error) {
-	var entry types.WAL_Entry
-	MustUnmarshal(data, &entry)
+	entry, err := UnmarshalEntry(data)
+	if err != nil {
+		return nil, err
+	}
 
-	if !isValidCRC(&entry) {
+	if !IsValidCRC(entry) {
 		return nil, fmt.Errorf("CRC mismatch: Data in the entry maybe be corrupted")
 	}
 
-	return &entry, nil
+	return entry, nil
 }
 
-func isValidCRC(entry *types.WAL_Entry) bool {
+// IsValidCRC reports whether entry's stored CRC32 matches its data.
+func IsValidCRC(entry *types.WAL_Entry) bool {
 	calculateNewCRC := crc32.ChecksumIEEE(append(entry.GetData(), byte(entry.GetLogSequenceNumber())))
 
 	return calculateNewCRC == entry.GetCRC()
@@ -7,22 +7,27 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func mustMarshal(entry *types.WAL_Entry) []byte {
+// MustMarshal marshals entry, panicking if it fails. Marshal should
+// never fail for a well-formed protobuf definition, so a failure here
+// means the codebase itself is broken, not the input.
+func MustMarshal(entry *types.WAL_Entry) []byte {
 	serializedEntry, err := proto.Marshal(entry)
-	
-	if err!=nil{
-		// marshal should never fail, if it fails it will be becuase of wrong protobuf definition or whole codebase
+
+	if err != nil {
 		panic(fmt.Sprintf("marshal failed: %v", err))
 	}
 
 	return serializedEntry
 }
 
-func mustUnmarshal(data []byte, entry *types.WAL_Entry){
-	err:= proto.Unmarshal(data, entry)
-
-	if err!=nil{
-		// similar to marshal, unmarshal should never fail too
-		panic(fmt.Sprintf("unmarshal failed: %v", err))
+// UnmarshalEntry decodes data into a WAL_Entry without panicking and
+// without verifying its CRC, for callers like Repair that expect to
+// encounter already-corrupt records.
+func UnmarshalEntry(data []byte) (*types.WAL_Entry, error) {
+	var entry types.WAL_Entry
+	if err := proto.Unmarshal(data, &entry); err != nil {
+		return nil, err
 	}
+
+	return &entry, nil
 }
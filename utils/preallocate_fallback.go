@@ -0,0 +1,17 @@
+package utils
+
+import "os"
+
+// preallocateFallback is used on platforms without a native fallocate,
+// and whenever fallocate itself reports ENOTSUP/EOPNOTSUPP. There is no
+// portable equivalent of FALLOC_FL_KEEP_SIZE: writing zero blocks out to
+// size and then truncating back down to the original size releases the
+// blocks that were just written on ordinary filesystems, so it reserves
+// nothing. Rather than pay that IO for no benefit, this is a documented
+// no-op - callers on these platforms still get correct preallocated
+// files (the next real write just pays the usual extension cost), they
+// just don't get the avoided-stall benefit Preallocate provides on
+// Linux.
+func preallocateFallback(file *os.File, size int64) error {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Preallocate reserves size bytes for file on disk using fallocate with
+// FALLOC_FL_KEEP_SIZE, so the apparent file size (what Stat reports)
+// stays at whatever has actually been written while the blocks are
+// reserved up front, avoiding the multi-millisecond extension stalls a
+// plain os.Create plus first write can hit at segment rotation.
+func Preallocate(file *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	err := unix.Fallocate(int(file.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+	if err == unix.ENOTSUP || err == unix.EOPNOTSUPP {
+		return preallocateFallback(file, size)
+	}
+
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package utils
+
+import "os"
+
+// Preallocate is a best-effort no-op on platforms without a native
+// fallocate: see preallocateFallback for why disk blocks can't be
+// reserved here without also growing the file's apparent size.
+func Preallocate(file *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	return preallocateFallback(file, size)
+}
@@ -0,0 +1,244 @@
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ThejasNU/wal/types"
+	"github.com/ThejasNU/wal/utils"
+)
+
+// Watcher tails a WAL directory live, decoding and emitting entries as
+// they are appended, and follows the writer across segment rotations.
+// It turns the WAL into a replication/CDC source: a follower, remote
+// write shipper, or materialized view can drain Entries instead of
+// polling ReadAllFromIndex in a loop.
+type Watcher struct {
+	directory string
+
+	// Entries delivers decoded entries in append order. The watcher
+	// blocks on a full channel, so callers must keep draining it.
+	Entries chan *types.WAL_Entry
+
+	// Errors delivers the error that stopped the watcher, if any.
+	Errors chan error
+
+	// notify wakes the tailer without waiting out its poll interval.
+	notify chan struct{}
+
+	// onStop, if set, is called once Stop's goroutine has returned so
+	// the watcher can deregister itself from whatever is notifying it.
+	// Set by WAL.Watch; nil for a Watcher constructed directly via
+	// NewWatcher.
+	onStop func()
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWatcher creates a Watcher over directory. Call Start to begin
+// tailing; WAL.Watch constructs one already wired up to be woken by
+// that WAL's writes.
+func NewWatcher(directory string) *Watcher {
+	return &Watcher{
+		directory: directory,
+		Entries:   make(chan *types.WAL_Entry, 128),
+		Errors:    make(chan error, 1),
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// Start begins tailing the WAL directory in a background goroutine,
+// starting at segment fromSegment and emitting only entries whose
+// LogSequenceNumber is greater than fromLSN.
+func (watcher *Watcher) Start(ctx context.Context, fromSegment int, fromLSN uint64) {
+	watcher.ctx, watcher.cancel = context.WithCancel(ctx)
+
+	watcher.wg.Add(1)
+	go func() {
+		defer watcher.wg.Done()
+
+		if err := watcher.run(fromSegment, fromLSN); err != nil {
+			select {
+			case watcher.Errors <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// Stop signals the watcher to exit, waits for its goroutine to return,
+// and deregisters it from whatever is notifying it.
+func (watcher *Watcher) Stop() {
+	if watcher.cancel != nil {
+		watcher.cancel()
+	}
+
+	watcher.wg.Wait()
+
+	if watcher.onStop != nil {
+		watcher.onStop()
+	}
+}
+
+// Notify wakes the watcher so it re-checks the current segment for new
+// bytes instead of waiting for its next poll interval.
+func (watcher *Watcher) Notify() {
+	select {
+	case watcher.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (watcher *Watcher) run(segmentIdx int, fromLSN uint64) error {
+	lastLSN := fromLSN
+
+	for {
+		segmentPath := filepath.Join(watcher.directory, fmt.Sprintf("%s%d", utils.SegmentPrefix, segmentIdx))
+
+		file, err := watcher.awaitSegment(segmentPath)
+		if err != nil {
+			return err
+		}
+
+		nextLSN, stopped, err := watcher.tailSegment(file, segmentIdx, lastLSN)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		if stopped {
+			return nil
+		}
+
+		lastLSN = nextLSN
+		segmentIdx++
+	}
+}
+
+// awaitSegment blocks until segmentPath exists or the watcher is
+// stopped.
+func (watcher *Watcher) awaitSegment(segmentPath string) (*os.File, error) {
+	for {
+		file, err := os.OpenFile(segmentPath, os.O_RDONLY, 0644)
+		if err == nil {
+			return file, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		select {
+		case <-watcher.ctx.Done():
+			return nil, watcher.ctx.Err()
+		case <-watcher.notify:
+		case <-time.After(utils.SyncInterval):
+		}
+	}
+}
+
+// tailSegment streams entries from file starting after lastLSN until
+// the next segment appears, blocking at EOF for either more bytes in
+// this segment or the rotation to segmentIdx+1. It returns the last LSN
+// it emitted and whether the watcher was stopped entirely.
+func (watcher *Watcher) tailSegment(file *os.File, segmentIdx int, lastLSN uint64) (uint64, bool, error) {
+	nextSegmentPath := filepath.Join(watcher.directory, fmt.Sprintf("%s%d", utils.SegmentPrefix, segmentIdx+1))
+
+	codec, offset, err := watcher.awaitSegmentHeader(file)
+	if err != nil {
+		return lastLSN, false, err
+	}
+
+	// the writer flushes a segment before creating the next one, so once
+	// the next segment exists one more pass over this one picks up
+	// everything that was appended to it
+	nextSegmentSeen := false
+
+	for {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return lastLSN, false, err
+		}
+
+		for {
+			var size int32
+			if err := binary.Read(file, binary.LittleEndian, &size); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return lastLSN, false, err
+			}
+
+			data := make([]byte, size)
+			if _, err := io.ReadFull(file, data); err != nil {
+				// a torn trailing write; wait for the rest of it to land
+				break
+			}
+
+			offset += int64(binary.Size(size)) + int64(len(data))
+
+			entry, err := codec.Decode(data)
+			if err != nil {
+				return lastLSN, false, err
+			}
+
+			if entry.GetLogSequenceNumber() <= lastLSN {
+				continue
+			}
+
+			lastLSN = entry.GetLogSequenceNumber()
+
+			select {
+			case watcher.Entries <- entry:
+			case <-watcher.ctx.Done():
+				return lastLSN, true, nil
+			}
+		}
+
+		if nextSegmentSeen {
+			return lastLSN, false, nil
+		}
+
+		if _, err := os.Stat(nextSegmentPath); err == nil {
+			nextSegmentSeen = true
+			continue
+		}
+
+		select {
+		case <-watcher.ctx.Done():
+			return lastLSN, true, nil
+		case <-watcher.notify:
+		case <-time.After(utils.SyncInterval):
+		}
+	}
+}
+
+// awaitSegmentHeader blocks until file's codec header byte has been
+// written, then returns the codec it names and the offset records
+// start at.
+func (watcher *Watcher) awaitSegmentHeader(file *os.File) (RecordCodec, int64, error) {
+	for {
+		codec, err := readSegmentHeader(file)
+		if err == nil && codec != nil {
+			if info, statErr := file.Stat(); statErr == nil && info.Size() >= segmentHeaderSize {
+				return codec, segmentHeaderSize, nil
+			}
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		select {
+		case <-watcher.ctx.Done():
+			return nil, 0, watcher.ctx.Err()
+		case <-watcher.notify:
+		case <-time.After(utils.SyncInterval):
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ThejasNU/wal/types"
 	"github.com/ThejasNU/wal/utils"
@@ -17,23 +18,53 @@ import (
 
 // writes in-memory buffer to the segment file in file system
 // if fsync is opted, syncs file to the disk
+//
+// in WBLMode, the fsync is skipped until groupCommitSize entries have
+// been buffered since the last one, coalescing many writers' flushes
+// into a single sync instead of paying fsync cost per entry
 func (wal *WAL) Flush() error {
 	if err := wal.bufferWriter.Flush(); err != nil {
 		return err
 	}
 
-	if wal.shouldFsync {
+	wal.metrics.setBufferedBytes(0)
+
+	if wal.shouldFsync && wal.readyForFsync() {
+		fsyncStart := time.Now()
+
 		if err := wal.currentSegment.Sync(); err != nil {
 			return err
 		}
+
+		if wal.mode == WBLMode {
+			wal.metrics.observeGroupCommit(wal.pendingGroupCommit, time.Since(fsyncStart))
+			wal.pendingGroupCommit = 0
+		} else {
+			wal.metrics.observeFsync(time.Since(fsyncStart))
+		}
 	}
 
 	// reset the timer, since we just synced
 	wal.resetTimer()
 
+	// wake any tailing watchers instead of leaving them to poll
+	wal.notifyWatchers()
+
 	return nil
 }
 
+// readyForFsync reports whether this Flush call should actually sync
+// the current segment. WALMode always does; WBLMode holds off until
+// groupCommitSize entries have accumulated since the last sync, so the
+// fsync cost is amortised across a group instead of paid per entry.
+func (wal *WAL) readyForFsync() bool {
+	if wal.mode != WBLMode {
+		return true
+	}
+
+	return wal.pendingGroupCommit >= wal.groupCommitSize
+}
+
 // writes entry to wal without creating checkpoint
 func (wal *WAL) WriteEntry(data []byte) error {
 	return wal.writeEntry(data, false)
@@ -97,6 +128,7 @@ func (wal *WAL) changeLogSegment() error {
 	}
 
 	wal.currentSegmentIndex += 1
+	wal.metrics.observeRotation(wal.currentSegmentIndex)
 
 	if wal.currentSegmentIndex > wal.maxSegmentsNumber {
 		if err := wal.deleteOldestSegment(); err != nil {
@@ -104,17 +136,50 @@ func (wal *WAL) changeLogSegment() error {
 		}
 	}
 
-	newFile, err := utils.CreateSegmentFile(wal.directory, int(wal.currentSegmentIndex))
+	newFile, err := wal.nextSegmentFile()
 	if err != nil {
 		return err
 	}
 
+	if err := writeSegmentHeader(newFile, wal.defaultCodec); err != nil {
+		newFile.Close()
+		return err
+	}
+
+	wal.codec = wal.defaultCodec
 	wal.currentSegment = newFile
-	wal.bufferWriter = bufio.NewWriter(newFile)
+	wal.bufferWriter = newBufferWriter(newFile, wal.bufferSize)
+
+	// the new segment file now exists on disk; let watchers waiting at
+	// EOF for it know right away instead of waiting out their poll
+	wal.notifyWatchers()
 
 	return nil
 }
 
+// nextSegmentFile returns the file to use for the new current segment:
+// an already-preallocated file handed off by the pipeline, renamed into
+// place, or - when Preallocate was not opted into - a freshly created
+// one.
+func (wal *WAL) nextSegmentFile() (*os.File, error) {
+	if wal.filePipeline == nil {
+		return utils.CreateSegmentFile(wal.directory, int(wal.currentSegmentIndex))
+	}
+
+	file, err := wal.filePipeline.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	segmentPath := filepath.Join(wal.directory, fmt.Sprintf("%s%d", utils.SegmentPrefix, wal.currentSegmentIndex))
+	if err := os.Rename(file.Name(), segmentPath); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
 func (wal *WAL) deleteOldestSegment() error {
 	files, err := filepath.Glob(filepath.Join(wal.directory, fmt.Sprintf("%s*", utils.SegmentPrefix)))
 	if err != nil {
@@ -135,6 +200,8 @@ func (wal *WAL) deleteOldestSegment() error {
 		return err
 	}
 
+	wal.metrics.observeSegmentDeleted()
+
 	return nil
 }
 
@@ -157,16 +224,30 @@ func (wal *WAL) findOldestSegmentFile(files []string) (string, error) {
 	return oldestSegmentFilePath, nil
 }
 
-// writes size of data of buffer and later the serialized entry
+// writes size of data of buffer and later the codec-encoded entry
 func (wal *WAL) writeEntryToBuffer(entry *types.WAL_Entry) error {
-	serializedEntry := utils.MustMarshal(entry)
+	if err := writeEntryToWriter(wal.bufferWriter, entry, wal.codec); err != nil {
+		return err
+	}
+
+	wal.pendingGroupCommit++
+	wal.metrics.observeWrite(len(entry.GetData()))
+	wal.metrics.setBufferedBytes(wal.bufferWriter.Buffered())
+
+	return nil
+}
+
+// writes the [size][codec-encoded entry] framing to w, shared by the
+// write path and checkpoint compaction
+func writeEntryToWriter(w *bufio.Writer, entry *types.WAL_Entry, codec RecordCodec) error {
+	serializedEntry := codec.Encode(entry)
 
 	size := int32(len(serializedEntry))
-	if err := binary.Write(wal.bufferWriter, binary.LittleEndian, size); err != nil {
+	if err := binary.Write(w, binary.LittleEndian, size); err != nil {
 		return err
 	}
 
-	_, err := wal.bufferWriter.Write(serializedEntry)
+	_, err := w.Write(serializedEntry)
 
 	return err
 }